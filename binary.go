@@ -0,0 +1,361 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Compact binary wire format for Filter:
+//
+//	magic(4) | version(1) | hasherID(1) | storeKind(1) | Size(8) | k(8) |
+//	  inserted(8) | body
+//
+// where body depends on storeKind: a dense Filter writes its Size-byte
+// array directly, a sparse one writes its set bit positions as
+// count(8) | position(8)*count. All multi-byte integers little-endian.
+// This is what ToJSON/FromJSON and ToFile/FromFile use under the hood, and
+// is what MarshalBinary/UnmarshalBinary (encoding.BinaryMarshaler/
+// Unmarshaler) produce directly, without the base64/JSON overhead.
+const (
+	binaryMagic      = "BLM1"
+	binaryVersion    = uint8(3)
+	binaryHeaderSize = len(binaryMagic) + 1 + 1 + 1 + 8 + 8 + 8
+)
+
+// storeKind records which bitStore implementation a binary-encoded Filter
+// body was written with, so UnmarshalBinary can decode it back without
+// promoting a sparse filter to dense on every round-trip.
+const (
+	storeKindDense  uint8 = 0
+	storeKindSparse uint8 = 1
+)
+
+// hasher ids recorded in the binary header. They identify which Hasher
+// produced a Filter; custom hashers (see WithHasher) cannot be serialized
+// as code, so they are recorded as hasherCustom and must be re-attached by
+// the caller (via FromBinary's opts) after decoding.
+const (
+	hasherMurmur3 uint8 = 0
+	hasherCustom  uint8 = 0xff
+)
+
+var errBadMagic = errors.New("bloom: not a recognized binary filter (bad magic)")
+
+// putHeaderFields writes Size, k and inserted (8 bytes each, little-endian)
+// to the start of dst, which must be at least 24 bytes long.
+func putHeaderFields(dst []byte, size uint64, k uint64, inserted uint64) {
+	binary.LittleEndian.PutUint64(dst[0:8], size)
+	binary.LittleEndian.PutUint64(dst[8:16], k)
+	binary.LittleEndian.PutUint64(dst[16:24], inserted)
+}
+
+// readHeaderFields reads back what putHeaderFields wrote.
+func readHeaderFields(src []byte) (size uint64, k uint64, inserted uint64) {
+	size = binary.LittleEndian.Uint64(src[0:8])
+	k = binary.LittleEndian.Uint64(src[8:16])
+	inserted = binary.LittleEndian.Uint64(src[16:24])
+	return
+}
+
+// encodeHeader writes the magic/version/hasherID/storeKind/Size/k/inserted
+// header shared by MarshalBinary and the mmap file format into a fresh
+// binaryHeaderSize buffer.
+func encodeHeader(hasherID uint8, storeKind uint8, size uint64, k uint64, inserted uint64) []byte {
+	header := make([]byte, binaryHeaderSize)
+	off := copy(header, binaryMagic)
+	header[off] = binaryVersion
+	header[off+1] = hasherID
+	header[off+2] = storeKind
+	putHeaderFields(header[off+3:], size, k, inserted)
+	return header
+}
+
+// decodeHeader parses the magic/version/hasherID/storeKind/Size/k/inserted
+// header shared by UnmarshalBinary and the mmap file format.
+func decodeHeader(data []byte) (hasherID uint8, storeKind uint8, size uint64, k uint64, inserted uint64, err error) {
+	if len(data) < binaryHeaderSize {
+		return 0, 0, 0, 0, 0, fmt.Errorf("bloom: truncated binary header: %d bytes", len(data))
+	}
+	if string(data[:len(binaryMagic)]) != binaryMagic {
+		return 0, 0, 0, 0, 0, errBadMagic
+	}
+	off := len(binaryMagic)
+	if version := data[off]; version != binaryVersion {
+		return 0, 0, 0, 0, 0, fmt.Errorf("bloom: unsupported binary version %d", version)
+	}
+	hasherID = data[off+1]
+	storeKind = data[off+2]
+	size, k, inserted = readHeaderFields(data[off+3:])
+	return hasherID, storeKind, size, k, inserted, nil
+}
+
+// MarshalBinary encodes bf into this package's compact binary wire format.
+// A dense Filter writes its byte array as-is; a sparse one writes only its
+// set bit positions, so a mostly-empty ScalableFilter layer doesn't pay for
+// megabytes of zero bytes on export. It implements
+// encoding.BinaryMarshaler.
+func (bf *Filter) MarshalBinary() ([]byte, error) {
+	switch s := bf.store.(type) {
+	case *denseStore:
+		header := encodeHeader(bf.hasherID, storeKindDense, bf.Size, bf.k, bf.inserted)
+		return append(header, s.arr...), nil
+	case *sparseStore:
+		header := encodeHeader(bf.hasherID, storeKindSparse, bf.Size, bf.k, bf.inserted)
+		buf := appendLEUint64(header, uint64(len(s.bits)))
+		for _, n := range s.bits {
+			buf = appendLEUint64(buf, n)
+		}
+		return buf, nil
+	default:
+		return nil, fmt.Errorf("bloom: unknown backing store %T", bf.store)
+	}
+}
+
+// UnmarshalBinary decodes data produced by MarshalBinary into bf. If the
+// filter was built with a custom Hasher, bf defaults back to Murmur3; pass
+// the same Hasher via FromBinary's opts to restore exact behavior.
+// It implements encoding.BinaryUnmarshaler.
+func (bf *Filter) UnmarshalBinary(data []byte) error {
+	hasherID, storeKind, size, k, inserted, err := decodeHeader(data)
+	if err != nil {
+		return err
+	}
+	body := data[binaryHeaderSize:]
+
+	switch storeKind {
+	case storeKindDense:
+		if uint64(len(body)) != size {
+			return fmt.Errorf("bloom: binary filter size mismatch: header says %d, got %d bytes", size, len(body))
+		}
+		arr := make([]byte, len(body))
+		copy(arr, body)
+		bf.store = &denseStore{arr: arr}
+	case storeKindSparse:
+		if len(body) < 8 {
+			return fmt.Errorf("bloom: truncated sparse binary filter: %d bytes", len(body))
+		}
+		off := 0
+		n := readLEUint64(body, &off)
+		if uint64(len(body)-off) != n*8 {
+			return fmt.Errorf("bloom: sparse binary filter bit count mismatch: expected %d positions", n)
+		}
+		bits := make([]uint64, n)
+		for i := range bits {
+			bits[i] = readLEUint64(body, &off)
+		}
+		bf.store = &sparseStore{bits: bits}
+	default:
+		return fmt.Errorf("bloom: unknown binary store kind %d", storeKind)
+	}
+
+	bf.Size = size
+	bf.k = k
+	bf.inserted = inserted
+	bf.hasherID = hasherID
+	bf.hasher = generateHasher(k, murmur3Hasher)
+	return nil
+}
+
+// FromBinary imports a Filter encoded with MarshalBinary. opts (e.g.
+// WithHasher) are applied after decoding, letting a caller restore a
+// custom Hasher that the wire format cannot carry.
+func FromBinary(data []byte, opts ...Option) (*Filter, error) {
+	bf := &Filter{}
+	if err := bf.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	for _, opt := range opts {
+		opt(&bf.hasherConfig)
+	}
+	return bf, nil
+}
+
+// willf/bits-and-blooms compatibility mode.
+//
+// That ecosystem's bloom.BloomFilter wraps a bits-and-blooms/bitset, whose
+// wire dump is: m(8) | k(8) | bitLength(8) | words([]uint64), all
+// big-endian, where m is the number of bits and each word packs 64 bits
+// LSB-first (bit i lives in word[i/64], bit i%64). ToWillfBinary/
+// FromWillfBinary read and write exactly that layout, so a filter can be
+// handed to (or received from) Loki/status-go or any other Go service
+// built on willf/bits-and-blooms. This only carries the raw bit array:
+// Filter partitions its m bits into k disjoint sections (see
+// generateHasher), one per hash function, while willf addresses the whole
+// range from every hash function. Those only agree when k == 1, so both
+// functions reject any other k rather than silently producing a Filter
+// whose Match doesn't actually correspond to the willf filter it came
+// from (or vice versa).
+func (bf *Filter) ToWillfBinary() ([]byte, error) {
+	if bf.k != 1 {
+		return nil, fmt.Errorf("bloom: ToWillfBinary only supports k=1 filters, got k=%d (willf addresses its full bit range from every hash; this package partitions it into k sections, so Match would not carry over)", bf.k)
+	}
+	m := bf.Size * 8
+	words := bitsToWords(bf.denseBytes(), m)
+	buf := make([]byte, 0, 24+8*len(words))
+	buf = appendBEUint64(buf, m)
+	buf = appendBEUint64(buf, bf.k)
+	buf = appendBEUint64(buf, m)
+	for _, w := range words {
+		buf = appendBEUint64(buf, w)
+	}
+	return buf, nil
+}
+
+// FromWillfBinary decodes a willf/bits-and-blooms bloom filter wire dump
+// into a Filter using this package's hasher; see ToWillfBinary for the
+// layout and its k==1 restriction.
+func FromWillfBinary(data []byte, opts ...Option) (*Filter, error) {
+	if len(data) < 24 {
+		return nil, fmt.Errorf("bloom: truncated willf binary filter: %d bytes", len(data))
+	}
+	m := binary.BigEndian.Uint64(data[0:8])
+	k := binary.BigEndian.Uint64(data[8:16])
+	bitLen := binary.BigEndian.Uint64(data[16:24])
+	if bitLen != m {
+		return nil, fmt.Errorf("bloom: willf bitset length %d does not match m %d", bitLen, m)
+	}
+	if k != 1 {
+		return nil, fmt.Errorf("bloom: FromWillfBinary only supports k=1 filters, got k=%d (willf addresses its full bit range from every hash; this package partitions it into k sections, so Match would not carry over)", k)
+	}
+	nwords := (m + 63) / 64
+	words := data[24:]
+	if uint64(len(words)) != nwords*8 {
+		return nil, fmt.Errorf("bloom: truncated willf bitset: expected %d words, got %d bytes", nwords, len(words))
+	}
+	bf := New((m+7)/8, k, opts...)
+	bf.store = &denseStore{arr: wordsToBits(words, m)}
+	return bf, nil
+}
+
+func bitsToWords(arr []byte, nbits uint64) []uint64 {
+	words := make([]uint64, (nbits+63)/64)
+	for i := uint64(0); i < nbits; i++ {
+		if (arr[i/8]>>(i%8))&1 == 1 {
+			words[i/64] |= 1 << (i % 64)
+		}
+	}
+	return words
+}
+
+func wordsToBits(wordBytes []byte, nbits uint64) []byte {
+	nwords := (nbits + 63) / 64
+	words := make([]uint64, nwords)
+	for i := uint64(0); i < nwords; i++ {
+		words[i] = binary.BigEndian.Uint64(wordBytes[i*8 : i*8+8])
+	}
+	arr := make([]byte, (nbits+7)/8)
+	for i := uint64(0); i < nbits; i++ {
+		if (words[i/64]>>(i%64))&1 == 1 {
+			arr[i/8] |= 1 << (i % 8)
+		}
+	}
+	return arr
+}
+
+func appendBEUint64(buf []byte, v uint64) []byte {
+	var tmp [8]byte
+	binary.BigEndian.PutUint64(tmp[:], v)
+	return append(buf, tmp[:]...)
+}
+
+func appendLEUint64(buf []byte, v uint64) []byte {
+	var tmp [8]byte
+	binary.LittleEndian.PutUint64(tmp[:], v)
+	return append(buf, tmp[:]...)
+}
+
+func appendLEFloat64(buf []byte, v float64) []byte {
+	return appendLEUint64(buf, math.Float64bits(v))
+}
+
+func readLEUint64(data []byte, off *int) uint64 {
+	v := binary.LittleEndian.Uint64(data[*off:])
+	*off += 8
+	return v
+}
+
+func readLEFloat64(data []byte, off *int) float64 {
+	return math.Float64frombits(readLEUint64(data, off))
+}
+
+// Compact binary wire format for ScalableFilter:
+//
+//	magic(4) | version(1) | s(8) | p(8) | m0(8) | r(8) | growthFillRatio(8) |
+//	  nLayers(8) | { len(8) | layer MarshalBinary blob }...
+//
+// all multi-byte fields little-endian, each layer encoded with Filter's own
+// MarshalBinary (including its own header).
+const scalableBinaryMagic = "BLMS"
+
+// MarshalBinary encodes sbf, including every layer and its own (p, s, r,
+// growthFillRatio) growth parameters, into this package's compact binary
+// wire format.
+func (sbf *ScalableFilter) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, scalableBinaryMagic...)
+	buf = append(buf, binaryVersion)
+	buf = appendLEFloat64(buf, sbf.s)
+	buf = appendLEFloat64(buf, sbf.p)
+	buf = appendLEUint64(buf, sbf.m0)
+	buf = appendLEFloat64(buf, sbf.r)
+	buf = appendLEFloat64(buf, sbf.growthFillRatio)
+	buf = appendLEUint64(buf, uint64(len(sbf.filters)))
+	for _, f := range sbf.filters {
+		fb, err := f.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		buf = appendLEUint64(buf, uint64(len(fb)))
+		buf = append(buf, fb...)
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes data produced by MarshalBinary into sbf.
+func (sbf *ScalableFilter) UnmarshalBinary(data []byte) error {
+	if len(data) < len(scalableBinaryMagic)+1 {
+		return fmt.Errorf("bloom: truncated scalable binary filter: %d bytes", len(data))
+	}
+	if string(data[:len(scalableBinaryMagic)]) != scalableBinaryMagic {
+		return errBadMagic
+	}
+	off := len(scalableBinaryMagic)
+	if version := data[off]; version != binaryVersion {
+		return fmt.Errorf("bloom: unsupported scalable binary version %d", version)
+	}
+	off++
+
+	sbf.s = readLEFloat64(data, &off)
+	sbf.p = readLEFloat64(data, &off)
+	sbf.m0 = readLEUint64(data, &off)
+	sbf.r = readLEFloat64(data, &off)
+	sbf.growthFillRatio = readLEFloat64(data, &off)
+	n := readLEUint64(data, &off)
+
+	filters := make([]*Filter, 0, n)
+	for i := uint64(0); i < n; i++ {
+		ln := readLEUint64(data, &off)
+		f := &Filter{}
+		if err := f.UnmarshalBinary(data[off : off+int(ln)]); err != nil {
+			return err
+		}
+		off += int(ln)
+		filters = append(filters, f)
+	}
+	sbf.filters = filters
+	return nil
+}
+
+// FromScalableBinary imports a ScalableFilter encoded with MarshalBinary.
+// opts (e.g. WithHasher) are applied to layers created after decoding, as
+// the filter keeps growing.
+func FromScalableBinary(data []byte, opts ...Option) (*ScalableFilter, error) {
+	sbf := &ScalableFilter{}
+	if err := sbf.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	sbf.opts = opts
+	return sbf, nil
+}
@@ -0,0 +1,119 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// TestBinaryRoundTrip : Test MarshalBinary/FromBinary round-trips a Filter
+func TestBinaryRoundTrip(t *testing.T) {
+	bf := New(FilterSize, 5)
+	for _, v := range tests {
+		bf.Feed(v)
+	}
+
+	raw, err := bf.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Could not marshal filter : %v", err)
+	}
+
+	cpy, err := FromBinary(raw)
+	if err != nil {
+		t.Fatalf("Could not unmarshal filter : %v", err)
+	}
+	for _, v := range tests {
+		if !cpy.Match(v) {
+			t.Errorf("Corrupted element when marshaling / unmarshaling : %s", v)
+		}
+	}
+}
+
+// TestWillfBinaryRoundTrip : Test that a single-hash Filter survives a
+// round-trip through the willf/bits-and-blooms wire layout
+func TestWillfBinaryRoundTrip(t *testing.T) {
+	bf := New(FilterSize, 1)
+	for _, v := range tests {
+		bf.Feed(v)
+	}
+
+	raw, err := bf.ToWillfBinary()
+	if err != nil {
+		t.Fatalf("Could not marshal willf-compatible filter : %v", err)
+	}
+
+	cpy, err := FromWillfBinary(raw)
+	if err != nil {
+		t.Fatalf("Could not unmarshal willf-compatible filter : %v", err)
+	}
+	for _, v := range tests {
+		if !cpy.Match(v) {
+			t.Errorf("Corrupted element when round-tripping willf binary : %s", v)
+		}
+	}
+}
+
+// TestWillfBinaryRejectsMultiHash : Test that ToWillfBinary/FromWillfBinary
+// refuse k != 1 filters instead of silently producing one whose Match
+// semantics don't correspond to the willf filter it came from
+func TestWillfBinaryRejectsMultiHash(t *testing.T) {
+	bf := New(FilterSize, 5)
+	if _, err := bf.ToWillfBinary(); err == nil {
+		t.Errorf("ToWillfBinary did not reject a k=5 filter")
+	}
+
+	single := New(FilterSize, 1)
+	raw, err := single.ToWillfBinary()
+	if err != nil {
+		t.Fatalf("Could not marshal willf-compatible filter : %v", err)
+	}
+	binary.BigEndian.PutUint64(raw[8:16], 5) // forge k=5 in the willf header
+	if _, err := FromWillfBinary(raw); err == nil {
+		t.Errorf("FromWillfBinary did not reject a k=5 willf filter")
+	}
+}
+
+// TestWillfBinaryNonByteAlignedSize : Test that a willf filter whose bit
+// count isn't a multiple of 8 round-trips without a Filter.Size/backing
+// array length mismatch
+func TestWillfBinaryNonByteAlignedSize(t *testing.T) {
+	const m = 100 // not a multiple of 8
+	buf := make([]byte, 0, 24+8*((m+63)/64))
+	buf = appendBEUint64(buf, m)
+	buf = appendBEUint64(buf, 1)
+	buf = appendBEUint64(buf, m)
+	for i := uint64(0); i < (m+63)/64; i++ {
+		buf = appendBEUint64(buf, 0)
+	}
+
+	bf, err := FromWillfBinary(buf)
+	if err != nil {
+		t.Fatalf("Could not unmarshal non-byte-aligned willf filter : %v", err)
+	}
+	if _, err := bf.MarshalBinary(); err != nil {
+		t.Errorf("MarshalBinary failed on a non-byte-aligned willf-imported filter : %v", err)
+	}
+}
+
+// TestScalableBinaryRoundTrip : Test MarshalBinary/FromScalableBinary
+// round-trips every layer of a ScalableFilter
+func TestScalableBinaryRoundTrip(t *testing.T) {
+	sbf := NewDefaultScalable(0.1)
+	for _, v := range tests {
+		sbf.Feed(v)
+	}
+
+	raw, err := sbf.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Could not marshal scalable filter : %v", err)
+	}
+
+	cpy, err := FromScalableBinary(raw)
+	if err != nil {
+		t.Fatalf("Could not unmarshal scalable filter : %v", err)
+	}
+	for _, v := range tests {
+		if !cpy.Match(v) {
+			t.Errorf("Corrupted element when marshaling / unmarshaling scalable filter : %s", v)
+		}
+	}
+}
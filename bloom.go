@@ -2,7 +2,6 @@
 package bloom
 
 import (
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -10,47 +9,92 @@ import (
 	"os"
 )
 
-// Hasher : Pluggable hasher type
-type Hasher func(string) uint64
+// Hasher : Pluggable 128-bit hasher type. It must return its digest split
+// into two 64-bit halves (h1, h2), which are then combined via
+// Kirsch-Mitzenmacher double hashing to derive the k bit positions. Defaults
+// to Murmur3 (see WithHasher), but any 128-bit hash works: xxhash, FNV-128,
+// or a custom one. Feed/Match pass in a zero-copy view of the input string,
+// so a Hasher must only read it, never retain or mutate it.
+type Hasher func([]byte) (uint64, uint64)
+
+// hasherConfig holds the hasher-related fields shared by Filter and
+// CountingFilter, so an Option can configure either one without the other
+// having to fake up a throwaway Filter just to reuse WithHasher.
+type hasherConfig struct {
+	k        uint64
+	hasher   func(inp []byte, dst []uint64)
+	hasherID uint8
+}
+
+// Option configures optional parameters on a Filter, CountingFilter or
+// ScalableFilter at construction time.
+type Option func(*hasherConfig)
+
+// WithHasher overrides the default Murmur3 Hasher used to derive bit
+// positions.
+func WithHasher(h Hasher) Option {
+	return func(c *hasherConfig) {
+		c.hasher = generateHasher(c.k, h)
+		c.hasherID = hasherCustom
+	}
+}
 
 // Filter : Implement a simple Filter
 type Filter struct {
-	arr      []byte
+	hasherConfig
+	store    bitStore
 	Size     uint64
-	k        uint64
 	inserted uint64
-	hasher   func([]byte) []uint64
+	hashBuf  []uint64 // reusable Feed/Match scratch buffer, see hashPositions
+	mmapFile *os.File // non-nil when store is backed by NewMmap/OpenMmap
 }
 
-// EncodedFilter is the JSON filter structure
+// EncodedFilter is the JSON filter structure. It is a thin wrapper around
+// the compact binary encoding (see MarshalBinary), so JSON and ToFile/
+// FromFile share a single on-disk representation.
 type EncodedFilter struct {
-	Arr      []byte
-	Size     uint64
-	K        uint64
-	Inserted uint64
+	Bin []byte
 }
 
 // New : constructor
-func New(size uint64, k uint64) *Filter {
-	return &Filter{
-		arr:      make([]byte, size),
-		k:        k,
+func New(size uint64, k uint64, opts ...Option) *Filter {
+	bf := &Filter{
+		hasherConfig: hasherConfig{
+			k:        k,
+			hasher:   generateHasher(k, murmur3Hasher),
+			hasherID: hasherMurmur3,
+		},
+		store:    newStore(),
 		Size:     size,
 		inserted: 0,
-		hasher:   generateHasher(k, size*8),
 	}
+	for _, opt := range opts {
+		opt(&bf.hasherConfig)
+	}
+	return bf
 }
 
-// Reset : zeroes the bytearray, flushing the filter
+// Reset : zeroes the bytearray, flushing the filter. An mmap-backed Filter
+// (NewMmap/OpenMmap) zeroes its existing mapped array in place instead of
+// replacing bf.store: Sync/Close type-assert bf.store back to the
+// *denseStore wrapping that mapping, so swapping it out here would leak the
+// mmap (no Munmap) and leave the on-disk file holding stale contents.
 func (bf *Filter) Reset() *Filter {
-	bf.arr = make([]byte, bf.Size)
+	if bf.mmapFile != nil {
+		arr := bf.store.(*denseStore).arr
+		for i := range arr {
+			arr[i] = 0
+		}
+		return bf
+	}
+	bf.store = newStore()
 	return bf
 }
 
 // Match : Check if s have an entry in the filter
 // May return false positive
 func (bf *Filter) Match(s string) bool {
-	hashs := bf.hasher([]byte(s))
+	hashs := bf.hashPositions(s)
 	sectionSize := ((bf.Size * 8) / bf.k)
 	for hid := uint64(0); hid < bf.k; hid++ {
 		start := hid * sectionSize
@@ -63,14 +107,11 @@ func (bf *Filter) Match(s string) bool {
 
 // ToJSON : Export a byte array that can be later used with bf.FromJSON
 func (bf *Filter) ToJSON() ([]byte, error) {
-	enc := &EncodedFilter{
-		Size:     bf.Size,
-		Arr:      bf.arr,
-		K:        bf.k,
-		Inserted: bf.inserted,
+	bin, err := bf.MarshalBinary()
+	if err != nil {
+		return nil, err
 	}
-
-	return json.Marshal(enc)
+	return json.Marshal(&EncodedFilter{Bin: bin})
 }
 
 // ToFile : Export filter to a file
@@ -90,30 +131,26 @@ func (bf *Filter) ToFile(path string) error {
 	return err
 }
 
-// FromFile : Import filter from a file
-func FromFile(path string) (*Filter, error) {
+// FromFile : Import filter from a file. opts (e.g. WithHasher) are applied
+// after decoding, letting a caller restore a custom Hasher that the wire
+// format cannot carry; see FromBinary.
+func FromFile(path string, opts ...Option) (*Filter, error) {
 	bytes, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	return FromJSON(bytes)
+	return FromJSON(bytes, opts...)
 }
 
-// FromJSON : Import a JSON serialized bloom filter
-func FromJSON(raw []byte) (*Filter, error) {
-	var dat map[string]interface{}
-
-	if err := json.Unmarshal(raw, &dat); err != nil {
+// FromJSON : Import a JSON serialized bloom filter. opts (e.g. WithHasher)
+// are applied after decoding, letting a caller restore a custom Hasher that
+// the wire format cannot carry; see FromBinary.
+func FromJSON(raw []byte, opts ...Option) (*Filter, error) {
+	var enc EncodedFilter
+	if err := json.Unmarshal(raw, &enc); err != nil {
 		return nil, err
 	}
-	bf := New(uint64(dat["Size"].(float64)), uint64(dat["K"].(float64)))
-	bf.inserted = uint64(dat["Inserted"].(float64))
-	n, err := base64.StdEncoding.DecodeString(dat["Arr"].(string))
-	if err != nil {
-		return nil, err
-	}
-	bf.arr = n
-	return bf, nil
+	return FromBinary(enc.Bin, opts...)
 }
 
 // Merge two Filters, filters must have the same size
@@ -127,27 +164,27 @@ func (bf *Filter) Merge(oth *Filter) error {
 	if bf.k != oth.k {
 		return fmt.Errorf("hashes functions must be the same to perform merge")
 	}
-	for i := uint64(0); i < bf.Size; i++ {
-		bf.arr[i] |= oth.arr[i]
-	}
+	bf.store = bf.store.merge(oth.store, bf.Size)
+	bf.maybePromote()
 	return nil
 }
 
 // Feed : Add an entry in the bloom filter
 func (bf *Filter) Feed(s string) *Filter {
-	hashs := bf.hasher([]byte(s))
+	hashs := bf.hashPositions(s)
 	sectionSize := ((bf.Size * 8) / bf.k)
 	for hid := uint64(0); hid < bf.k; hid++ {
 		start := hid * sectionSize
 		bf.setBit(start + (hashs[hid] % (sectionSize)))
 	}
 	bf.inserted++
+	bf.maybePromote()
 	return bf
 }
 
 // FillRatio : Count each set bit into the Filter to compute the fillRatio
 func (bf *Filter) FillRatio() float64 {
-	return float64(popcntSliceGo(bf.arr)) / float64(bf.Size*8)
+	return float64(bf.store.popcount()) / float64(bf.Size*8)
 }
 
 // EstimateFillRatio : Optimization of the fillRatio function, estimate instead of counting bits
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"crypto/rand"
 	"fmt"
+	"hash/fnv"
 	"os"
 	"reflect"
 	"testing"
@@ -77,7 +78,7 @@ func TestDeserialization(t *testing.T) {
 		t.Errorf("Could not import element : %v", err)
 
 	}
-	if !reflect.DeepEqual(bf.arr, cpy.arr) {
+	if !reflect.DeepEqual(bf.denseBytes(), cpy.denseBytes()) {
 		t.Errorf("Corrupted element when serializing / deserializing : %v", err)
 		fmt.Println("===============ORIGINAL=====================")
 		fmt.Println(bf)
@@ -106,6 +107,57 @@ func TestExport(t *testing.T) {
 	}
 }
 
+// TestWithHasher : Test that a custom Hasher passed through WithHasher is
+// actually used instead of the default Murmur3 one
+func TestWithHasher(t *testing.T) {
+	calls := 0
+	custom := func(data []byte) (uint64, uint64) {
+		calls++
+		return murmur3Hasher(data)
+	}
+
+	bf := New(FilterSize, 5, WithHasher(custom))
+	bf.Feed("Bloom")
+	if calls == 0 {
+		t.Errorf("custom Hasher passed to WithHasher was never called")
+	}
+	if !bf.Match("Bloom") {
+		t.Errorf("Filter using a custom Hasher did not match an inserted element")
+	}
+}
+
+// TestWithHasherJSONRoundTrip : Test that a custom Hasher passed back to
+// FromJSON survives a ToJSON/FromJSON round trip, so a Filter relying on it
+// does not silently lose previously fed elements
+func TestWithHasherJSONRoundTrip(t *testing.T) {
+	custom := func(data []byte) (uint64, uint64) {
+		h1, h2 := fnv.New64a(), fnv.New64a()
+		h1.Write(data)
+		h2.Write(append(data, 0xff))
+		return h1.Sum64(), h2.Sum64()
+	}
+
+	bf := New(FilterSize, 5, WithHasher(custom))
+	for _, v := range tests {
+		bf.Feed(v)
+	}
+
+	raw, err := bf.ToJSON()
+	if err != nil {
+		t.Fatalf("Could not serialize element : %v", err)
+	}
+
+	cpy, err := FromJSON(raw, WithHasher(custom))
+	if err != nil {
+		t.Fatalf("Could not import element : %v", err)
+	}
+	for _, v := range tests {
+		if !cpy.Match(v) {
+			t.Errorf("Element fed under a custom Hasher lost across ToJSON/FromJSON : %s", v)
+		}
+	}
+}
+
 // TestMerge : Test the merge functionnality
 func TestMerge(t *testing.T) {
 	bf := New(FilterSize, 5)
@@ -220,6 +272,22 @@ func BenchmarkMatch1(b *testing.B)  { BenchMatch(1, b) }
 func BenchmarkMatch5(b *testing.B)  { BenchMatch(5, b) }
 func BenchmarkMatch10(b *testing.B) { BenchMatch(10, b) }
 
+// TestFeedMatchAllocFree : Assert that Feed/Match don't allocate once the
+// Filter's reusable hash scratch buffer has warmed up, which matters most
+// for the mmap-backed variant where the hot path is otherwise the cost of a
+// single page write.
+func TestFeedMatchAllocFree(t *testing.T) {
+	bf := New(FilterSize, 5)
+	bf.Feed("warmup") // grow bf.hashBuf before measuring
+
+	if n := testing.AllocsPerRun(100, func() { bf.Feed("I am a test string") }); n != 0 {
+		t.Errorf("Feed allocated %v times per call, want 0", n)
+	}
+	if n := testing.AllocsPerRun(100, func() { bf.Match("I am a test string") }); n != 0 {
+		t.Errorf("Match allocated %v times per call, want 0", n)
+	}
+}
+
 // INTERNAL ROUTINE : isSet
 
 func BenchIsSet(b *testing.B) {
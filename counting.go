@@ -0,0 +1,211 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+)
+
+// CounterWidth is the number of bits used per counter in a CountingFilter.
+type CounterWidth uint8
+
+// Supported counter widths. Wider counters tolerate more repeated
+// insertions of the same key before saturating, at the cost of memory.
+const (
+	Counter4  CounterWidth = 4
+	Counter8  CounterWidth = 8
+	Counter16 CounterWidth = 16
+)
+
+// max returns the saturation value for this counter width.
+func (w CounterWidth) max() uint64 {
+	return (uint64(1) << uint(w)) - 1
+}
+
+// CountingFilter generalizes Filter to an array of small saturating
+// counters instead of single bits. Unlike Filter it supports Remove, and
+// Count gives a Count-Min style estimate of how many times a key was fed.
+type CountingFilter struct {
+	hasherConfig
+	arr      []byte
+	Size     uint64 // number of counters
+	width    CounterWidth
+	inserted uint64
+	hashBuf  []uint64 // reusable positions() scratch buffer, see Filter.hashPositions
+}
+
+// EncodedCountingFilter is the JSON CountingFilter structure
+type EncodedCountingFilter struct {
+	Arr      []byte
+	Size     uint64
+	K        uint64
+	Width    uint8
+	Inserted uint64
+}
+
+// NewCounting : constructor. size is the number of counters (not bytes), k
+// the number of hash functions, width the number of bits per counter
+// (Counter4, Counter8 or Counter16). Returns an error if width isn't one of
+// those three, instead of deferring the failure to a panic the first time a
+// counter is read or written.
+func NewCounting(size uint64, k uint64, width CounterWidth, opts ...Option) (*CountingFilter, error) {
+	switch width {
+	case Counter4, Counter8, Counter16:
+	default:
+		return nil, fmt.Errorf("bloom: unsupported counter width: %d", width)
+	}
+
+	cf := &CountingFilter{
+		hasherConfig: hasherConfig{
+			k:        k,
+			hasher:   generateHasher(k, murmur3Hasher),
+			hasherID: hasherMurmur3,
+		},
+		arr:      make([]byte, (size*uint64(width)+7)/8),
+		Size:     size,
+		width:    width,
+		inserted: 0,
+	}
+	for _, opt := range opts {
+		opt(&cf.hasherConfig)
+	}
+	return cf, nil
+}
+
+// counterAt returns the value of the idx-th counter.
+func (cf *CountingFilter) counterAt(idx uint64) uint64 {
+	bitOffset := idx * uint64(cf.width)
+	byteIdx := bitOffset / 8
+	switch cf.width {
+	case Counter4:
+		if bitOffset%8 == 0 {
+			return uint64(cf.arr[byteIdx] & 0x0f)
+		}
+		return uint64(cf.arr[byteIdx] >> 4)
+	case Counter8:
+		return uint64(cf.arr[byteIdx])
+	case Counter16:
+		return uint64(binary.LittleEndian.Uint16(cf.arr[byteIdx : byteIdx+2]))
+	default:
+		panic(fmt.Sprintf("unsupported counter width: %d", cf.width))
+	}
+}
+
+// setCounterAt sets the idx-th counter to v, which must already be within
+// [0, cf.width.max()].
+func (cf *CountingFilter) setCounterAt(idx uint64, v uint64) {
+	bitOffset := idx * uint64(cf.width)
+	byteIdx := bitOffset / 8
+	switch cf.width {
+	case Counter4:
+		if bitOffset%8 == 0 {
+			cf.arr[byteIdx] = (cf.arr[byteIdx] & 0xf0) | byte(v)
+		} else {
+			cf.arr[byteIdx] = (cf.arr[byteIdx] & 0x0f) | byte(v<<4)
+		}
+	case Counter8:
+		cf.arr[byteIdx] = byte(v)
+	case Counter16:
+		binary.LittleEndian.PutUint16(cf.arr[byteIdx:byteIdx+2], uint16(v))
+	default:
+		panic(fmt.Sprintf("unsupported counter width: %d", cf.width))
+	}
+}
+
+// positions returns the k counter indices for s.
+func (cf *CountingFilter) positions(s string) []uint64 {
+	if uint64(len(cf.hashBuf)) != cf.k {
+		cf.hashBuf = make([]uint64, cf.k)
+	}
+	cf.hasher(bytesView(s), cf.hashBuf)
+	sectionSize := cf.Size / cf.k
+	idxs := make([]uint64, cf.k)
+	for hid := uint64(0); hid < cf.k; hid++ {
+		start := hid * sectionSize
+		idxs[hid] = start + (cf.hashBuf[hid] % sectionSize)
+	}
+	return idxs
+}
+
+// Feed : Add an entry in the counting bloom filter, incrementing each of its
+// k counters (saturating at the configured width).
+func (cf *CountingFilter) Feed(s string) *CountingFilter {
+	max := cf.width.max()
+	for _, idx := range cf.positions(s) {
+		if v := cf.counterAt(idx); v < max {
+			cf.setCounterAt(idx, v+1)
+		}
+	}
+	cf.inserted++
+	return cf
+}
+
+// Match : Check if s have an entry in the filter
+// May return false positive
+func (cf *CountingFilter) Match(s string) bool {
+	for _, idx := range cf.positions(s) {
+		if cf.counterAt(idx) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Remove : Decrement each of s's k counters, undoing a previous Feed.
+// Refuses to decrement (and returns false) if any of the k counters is
+// already zero, since that would corrupt counters shared with other keys.
+func (cf *CountingFilter) Remove(s string) bool {
+	idxs := cf.positions(s)
+	for _, idx := range idxs {
+		if cf.counterAt(idx) == 0 {
+			return false
+		}
+	}
+	for _, idx := range idxs {
+		cf.setCounterAt(idx, cf.counterAt(idx)-1)
+	}
+	cf.inserted--
+	return true
+}
+
+// Count : returns the minimum of s's k counters, a Count-Min style estimate
+// of how many times s was fed (and not yet fully removed).
+func (cf *CountingFilter) Count(s string) uint64 {
+	min := cf.width.max()
+	for _, idx := range cf.positions(s) {
+		if v := cf.counterAt(idx); v < min {
+			min = v
+		}
+	}
+	return min
+}
+
+// ToJSON : Export a byte array that can be later used with FromCountingJSON
+func (cf *CountingFilter) ToJSON() ([]byte, error) {
+	enc := &EncodedCountingFilter{
+		Size:     cf.Size,
+		Arr:      cf.arr,
+		K:        cf.k,
+		Width:    uint8(cf.width),
+		Inserted: cf.inserted,
+	}
+	return json.Marshal(enc)
+}
+
+// FromCountingJSON : Import a JSON serialized CountingFilter
+func FromCountingJSON(raw []byte) (*CountingFilter, error) {
+	var enc EncodedCountingFilter
+	if err := json.Unmarshal(raw, &enc); err != nil {
+		return nil, err
+	}
+	cf, err := NewCounting(enc.Size, enc.K, CounterWidth(enc.Width))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(enc.Arr)) != uint64(len(cf.arr)) {
+		return nil, fmt.Errorf("bloom: counting filter array size mismatch: expected %d bytes, got %d", len(cf.arr), len(enc.Arr))
+	}
+	cf.arr = enc.Arr
+	cf.inserted = enc.Inserted
+	return cf, nil
+}
@@ -0,0 +1,101 @@
+package bloom
+
+import "testing"
+
+// TestCountingMatch : Test that inserted elements return true upon Match
+func TestCountingMatch(t *testing.T) {
+	cf, err := NewCounting(FilterSize, 5, Counter4)
+	if err != nil {
+		t.Fatalf("Could not create counting filter : %v", err)
+	}
+	for _, v := range tests {
+		cf.Feed(v)
+		if !cf.Match(v) {
+			t.Errorf("CountingFilter match return false on inserted element : %s", v)
+		}
+	}
+}
+
+// TestCountingRemove : Test that Remove undoes a Feed and refuses to
+// decrement counters shared with another still-present key
+func TestCountingRemove(t *testing.T) {
+	cf, err := NewCounting(FilterSize, 5, Counter4)
+	if err != nil {
+		t.Fatalf("Could not create counting filter : %v", err)
+	}
+	cf.Feed("Bloom")
+
+	if !cf.Remove("Bloom") {
+		t.Errorf("Remove failed on a previously fed element")
+	}
+	if cf.Match("Bloom") {
+		t.Errorf("Element still matched after being removed")
+	}
+	if cf.Remove("Bloom") {
+		t.Errorf("Remove succeeded twice on an element with saturated-to-zero counters")
+	}
+}
+
+// TestCountingCount : Test that Count reflects the number of times an
+// element was fed
+func TestCountingCount(t *testing.T) {
+	cf, err := NewCounting(FilterSize, 5, Counter8)
+	if err != nil {
+		t.Fatalf("Could not create counting filter : %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		cf.Feed("Bloom")
+	}
+	if c := cf.Count("Bloom"); c != 3 {
+		t.Errorf("Expected count of 3, got %d", c)
+	}
+}
+
+// TestCountingSerialization : Test JSON round-trip for a CountingFilter
+func TestCountingSerialization(t *testing.T) {
+	cf, err := NewCounting(FilterSize, 5, Counter16)
+	if err != nil {
+		t.Fatalf("Could not create counting filter : %v", err)
+	}
+	for _, v := range tests {
+		cf.Feed(v)
+	}
+
+	raw, err := cf.ToJSON()
+	if err != nil {
+		t.Errorf("Could not serialize element : %v", err)
+	}
+
+	cpy, err := FromCountingJSON(raw)
+	if err != nil {
+		t.Errorf("Could not import element : %v", err)
+	}
+	for _, v := range tests {
+		if !cpy.Match(v) {
+			t.Errorf("Corrupted element when serializing / deserializing : %s", v)
+		}
+	}
+}
+
+// TestCountingInvalidWidth : Test that NewCounting rejects an unsupported
+// counter width at construction instead of panicking the first time a
+// counter is read or written
+func TestCountingInvalidWidth(t *testing.T) {
+	if _, err := NewCounting(FilterSize, 5, CounterWidth(3)); err == nil {
+		t.Errorf("NewCounting did not reject an unsupported counter width")
+	}
+}
+
+// TestCountingFromJSONRejectsMalformed : Test that FromCountingJSON returns
+// an error instead of panicking on malformed or mismatched input
+func TestCountingFromJSONRejectsMalformed(t *testing.T) {
+	if _, err := FromCountingJSON([]byte(`not json`)); err == nil {
+		t.Errorf("FromCountingJSON did not reject non-JSON input")
+	}
+	if _, err := FromCountingJSON([]byte(`{"Size": 8, "K": 5, "Width": 3}`)); err == nil {
+		t.Errorf("FromCountingJSON did not reject an unsupported counter width")
+	}
+	if _, err := FromCountingJSON([]byte(`{"Size": 8, "K": 5, "Width": 4, "Arr": "AA=="}`)); err == nil {
+		t.Errorf("FromCountingJSON did not reject an Arr of the wrong length for Size/Width")
+	}
+}
@@ -0,0 +1,142 @@
+//go:build !windows
+
+package bloom
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// On-disk layout for a mmap-backed Filter: the binary header from
+// MarshalBinary, padded out to a page boundary, followed by the raw bit
+// array (Size bytes) at that page-aligned offset. Padding the header lets
+// the bit array be mmap'd directly (mmap requires a page-aligned file
+// offset) while setBit/isSet keep writing straight into the mapped region,
+// so Feed/Match stay as cheap as the in-memory Filter.
+var mmapPageSize = os.Getpagesize()
+
+func mmapDataOffset() int64 {
+	ps := int64(mmapPageSize)
+	return ((int64(binaryHeaderSize) + ps - 1) / ps) * ps
+}
+
+// NewMmap creates a new Filter of size bytes and k hash functions backed by
+// a freshly created, mmap'd file at path, so the bit array can grow larger
+// than RAM and survive process restarts without a full JSON reload. The
+// returned Filter must be Close()d to release the mapping.
+func NewMmap(path string, size uint64, k uint64, opts ...Option) (*Filter, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	dataOffset := mmapDataOffset()
+	if err := f.Truncate(dataOffset + int64(size)); err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, err
+	}
+
+	bf := New(size, k, opts...)
+	if _, err := f.WriteAt(encodeHeader(bf.hasherID, storeKindDense, bf.Size, bf.k, bf.inserted), 0); err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, err
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), dataOffset, int(size), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, err
+	}
+
+	bf.store = &denseStore{arr: data}
+	bf.mmapFile = f
+	return bf, nil
+}
+
+// OpenMmap reopens a Filter previously created with NewMmap, mapping its
+// bit array back into memory without reading or copying it. The returned
+// Filter must be Close()d to release the mapping.
+func OpenMmap(path string, opts ...Option) (*Filter, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, binaryHeaderSize)
+	if _, err := f.ReadAt(header, 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	hasherID, _, size, k, inserted, err := decodeHeader(header)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	bf := &Filter{
+		hasherConfig: hasherConfig{
+			k:        k,
+			hasher:   generateHasher(k, murmur3Hasher),
+			hasherID: hasherID,
+		},
+		Size:     size,
+		inserted: inserted,
+	}
+	for _, opt := range opts {
+		opt(&bf.hasherConfig)
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), mmapDataOffset(), int(size), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	bf.store = &denseStore{arr: data}
+	bf.mmapFile = f
+	return bf, nil
+}
+
+// Sync flushes the mapped bit array and its header to disk. It is a no-op
+// for Filters not backed by NewMmap/OpenMmap.
+func (bf *Filter) Sync() error {
+	if bf.mmapFile == nil {
+		return nil
+	}
+	if _, err := bf.mmapFile.WriteAt(encodeHeader(bf.hasherID, storeKindDense, bf.Size, bf.k, bf.inserted), 0); err != nil {
+		return err
+	}
+	arr := bf.store.(*denseStore).arr
+	if len(arr) == 0 {
+		return nil
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_MSYNC,
+		uintptr(unsafe.Pointer(&arr[0])), uintptr(len(arr)), uintptr(syscall.MS_SYNC))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Close flushes and releases the memory mapping and the underlying file.
+// It is a no-op for Filters not backed by NewMmap/OpenMmap.
+func (bf *Filter) Close() error {
+	if bf.mmapFile == nil {
+		return nil
+	}
+	if err := bf.Sync(); err != nil {
+		bf.mmapFile.Close()
+		return err
+	}
+	if err := syscall.Munmap(bf.store.(*denseStore).arr); err != nil {
+		bf.mmapFile.Close()
+		return err
+	}
+	err := bf.mmapFile.Close()
+	bf.mmapFile = nil
+	return err
+}
@@ -0,0 +1,96 @@
+package bloom
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestMmapPersist : Test that a NewMmap filter survives a Close/OpenMmap
+// round-trip through the same file
+func TestMmapPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filter.mmap")
+
+	bf, err := NewMmap(path, FilterSize, 5)
+	if err != nil {
+		t.Fatalf("Could not create mmap filter : %v", err)
+	}
+	for _, v := range tests {
+		bf.Feed(v)
+	}
+	if err := bf.Close(); err != nil {
+		t.Fatalf("Could not close mmap filter : %v", err)
+	}
+
+	cpy, err := OpenMmap(path)
+	if err != nil {
+		t.Fatalf("Could not reopen mmap filter : %v", err)
+	}
+	defer cpy.Close()
+
+	for _, v := range tests {
+		if !cpy.Match(v) {
+			t.Errorf("Element lost across mmap Close/OpenMmap : %s", v)
+		}
+	}
+}
+
+// TestMmapSync : Test that Sync does not error and its writes are visible
+// to a second mapping of the same file
+func TestMmapSync(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filter.mmap")
+
+	bf, err := NewMmap(path, FilterSize, 5)
+	if err != nil {
+		t.Fatalf("Could not create mmap filter : %v", err)
+	}
+	defer bf.Close()
+
+	bf.Feed("Bloom")
+	if err := bf.Sync(); err != nil {
+		t.Errorf("Sync returned an error : %v", err)
+	}
+
+	cpy, err := OpenMmap(path)
+	if err != nil {
+		t.Fatalf("Could not reopen synced mmap filter : %v", err)
+	}
+	defer cpy.Close()
+	if !cpy.Match("Bloom") {
+		t.Errorf("Synced element not visible to a second mapping")
+	}
+}
+
+// TestMmapReset : Test that Reset on an mmap-backed Filter zeroes its
+// mapped array in place and leaves it safe to Sync/Close afterwards,
+// instead of panicking on the Sync/Close type assertion back to
+// *denseStore or leaking the mapping
+func TestMmapReset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filter.mmap")
+
+	bf, err := NewMmap(path, FilterSize, 5)
+	if err != nil {
+		t.Fatalf("Could not create mmap filter : %v", err)
+	}
+	defer bf.Close()
+
+	bf.Feed("Bloom")
+	bf.Reset()
+	if bf.Match("Bloom") {
+		t.Errorf("Element still matched after Reset")
+	}
+	if err := bf.Sync(); err != nil {
+		t.Errorf("Sync returned an error after Reset : %v", err)
+	}
+	if err := bf.Close(); err != nil {
+		t.Errorf("Close returned an error after Reset : %v", err)
+	}
+
+	cpy, err := OpenMmap(path)
+	if err != nil {
+		t.Fatalf("Could not reopen reset mmap filter : %v", err)
+	}
+	defer cpy.Close()
+	if cpy.Match("Bloom") {
+		t.Errorf("Reset filter did not persist as empty across Close/OpenMmap")
+	}
+}
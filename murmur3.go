@@ -0,0 +1,136 @@
+package bloom
+
+// Implementation of the 128-bit x64 variant of MurmurHash3
+// (https://github.com/aappleby/smhasher). It is not cryptographic, but it is
+// fast and has good avalanche properties, which is all a Bloom filter needs.
+
+const (
+	murmurC1 = 0x87c37b91114253d5
+	murmurC2 = 0x4cf5ad432745937f
+)
+
+func murmurMix(k uint64) uint64 {
+	k ^= k >> 33
+	k *= 0xff51afd7ed558ccd
+	k ^= k >> 33
+	k *= 0xc4ceb9fe1a85ec53
+	k ^= k >> 33
+	return k
+}
+
+func rotl64(x uint64, r uint8) uint64 {
+	return (x << r) | (x >> (64 - r))
+}
+
+// murmur3Sum128 computes the 128-bit MurmurHash3 of data using seed, split
+// into its two 64-bit halves.
+func murmur3Sum128(data []byte, seed uint32) (h1 uint64, h2 uint64) {
+	h1, h2 = uint64(seed), uint64(seed)
+	nblocks := len(data) / 16
+
+	for i := 0; i < nblocks; i++ {
+		block := data[i*16 : (i+1)*16]
+		k1 := le64(block[0:8])
+		k2 := le64(block[8:16])
+
+		k1 *= murmurC1
+		k1 = rotl64(k1, 31)
+		k1 *= murmurC2
+		h1 ^= k1
+
+		h1 = rotl64(h1, 27)
+		h1 += h2
+		h1 = h1*5 + 0x52dce729
+
+		k2 *= murmurC2
+		k2 = rotl64(k2, 33)
+		k2 *= murmurC1
+		h2 ^= k2
+
+		h2 = rotl64(h2, 31)
+		h2 += h1
+		h2 = h2*5 + 0x38495ab5
+	}
+
+	tail := data[nblocks*16:]
+	var k1, k2 uint64
+	switch len(tail) {
+	case 15:
+		k2 ^= uint64(tail[14]) << 48
+		fallthrough
+	case 14:
+		k2 ^= uint64(tail[13]) << 40
+		fallthrough
+	case 13:
+		k2 ^= uint64(tail[12]) << 32
+		fallthrough
+	case 12:
+		k2 ^= uint64(tail[11]) << 24
+		fallthrough
+	case 11:
+		k2 ^= uint64(tail[10]) << 16
+		fallthrough
+	case 10:
+		k2 ^= uint64(tail[9]) << 8
+		fallthrough
+	case 9:
+		k2 ^= uint64(tail[8])
+		k2 *= murmurC2
+		k2 = rotl64(k2, 33)
+		k2 *= murmurC1
+		h2 ^= k2
+		fallthrough
+	case 8:
+		k1 ^= uint64(tail[7]) << 56
+		fallthrough
+	case 7:
+		k1 ^= uint64(tail[6]) << 48
+		fallthrough
+	case 6:
+		k1 ^= uint64(tail[5]) << 40
+		fallthrough
+	case 5:
+		k1 ^= uint64(tail[4]) << 32
+		fallthrough
+	case 4:
+		k1 ^= uint64(tail[3]) << 24
+		fallthrough
+	case 3:
+		k1 ^= uint64(tail[2]) << 16
+		fallthrough
+	case 2:
+		k1 ^= uint64(tail[1]) << 8
+		fallthrough
+	case 1:
+		k1 ^= uint64(tail[0])
+		k1 *= murmurC1
+		k1 = rotl64(k1, 31)
+		k1 *= murmurC2
+		h1 ^= k1
+	}
+
+	h1 ^= uint64(len(data))
+	h2 ^= uint64(len(data))
+
+	h1 += h2
+	h2 += h1
+
+	h1 = murmurMix(h1)
+	h2 = murmurMix(h2)
+
+	h1 += h2
+	h2 += h1
+
+	return h1, h2
+}
+
+func le64(b []byte) uint64 {
+	return uint64(b[0]) | uint64(b[1])<<8 | uint64(b[2])<<16 | uint64(b[3])<<24 |
+		uint64(b[4])<<32 | uint64(b[5])<<40 | uint64(b[6])<<48 | uint64(b[7])<<56
+}
+
+// murmur3Hasher is the default Hasher, backed by a seed-0 128-bit
+// MurmurHash3 digest.
+func murmur3Hasher(data []byte) (uint64, uint64) {
+	return murmur3Sum128(data, 0)
+}
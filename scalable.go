@@ -1,6 +1,16 @@
 package bloom
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+)
+
+// defaultGrowthFillRatio is the EstimateFillRatio a layer must cross before
+// ScalableFilter.Feed adds a new one on top of it. ln(2) is the fill ratio
+// that maximizes a single Filter's information-per-bit (see the Almeida et
+// al. scalable Bloom filter paper); growing any earlier wastes capacity by
+// adding layers (and Match overhead) sooner than necessary.
+const defaultGrowthFillRatio = math.Ln2
 
 // ScalableFilter can handle situation where filter total number
 // of elements is undetermined at instantiation
@@ -10,30 +20,38 @@ import "fmt"
 // m0: size of the initial filter
 // r: tightning ratio, like 's' but for false positive precision
 type ScalableFilter struct {
-	s       float64
-	p       float64
-	m0      uint64
-	r       float64
-	filters []*Filter
+	s               float64
+	p               float64
+	m0              uint64
+	r               float64
+	growthFillRatio float64
+	opts            []Option
+	filters         []*Filter
 }
 
-// NewScalable Create a new ScalableFilter
-func NewScalable(p float64, s float64, m0 uint64, r float64) *ScalableFilter {
+// NewScalable Create a new ScalableFilter. growthFillRatio is the
+// EstimateFillRatio a layer must cross before a new one is added on top of
+// it (see defaultGrowthFillRatio for the theoretically optimal value). opts
+// (e.g. WithHasher) are applied to every layer, including the ones created
+// as the filter grows.
+func NewScalable(p float64, s float64, m0 uint64, r float64, growthFillRatio float64, opts ...Option) *ScalableFilter {
 	filts := make([]*Filter, 1)
-	filts[0] = New(m0, hashCountForFP(p))
+	filts[0] = New(m0, hashCountForFP(p), opts...)
 	return &ScalableFilter{
-		s:       s,
-		p:       p,
-		m0:      m0,
-		r:       r,
-		filters: filts,
+		s:               s,
+		p:               p,
+		m0:              m0,
+		r:               r,
+		growthFillRatio: growthFillRatio,
+		opts:            opts,
+		filters:         filts,
 	}
 }
 
 // NewDefaultScalable create a new ScalableFilter with default arguments
 // More details on arguments : http://gsd.di.uminho.pt/members/cbm/ps/dbloom.pdf
-func NewDefaultScalable(p float64) *ScalableFilter {
-	return NewScalable(p, 2.0, 1024, 0.8)
+func NewDefaultScalable(p float64, opts ...Option) *ScalableFilter {
+	return NewScalable(p, 2.0, 1024, 0.8, defaultGrowthFillRatio, opts...)
 }
 
 // Match : Check if s have an entry in the filter
@@ -61,14 +79,57 @@ func (sbf *ScalableFilter) dumpsFilters() {
 func (sbf *ScalableFilter) Feed(s string) *ScalableFilter {
 	// fmt.Printf("[R]: %.5f | [E]: %.5f\n",
 	// sbf.filters[0].fillRatio(), sbf.filters[0].estimateFillRatio())
-	if sbf.filters[0].EstimateFillRatio() > 0.3 {
+	if sbf.filters[0].EstimateFillRatio() > sbf.growthFillRatio {
 		sbf.p *= sbf.r
 		sbf.filters = append(make([]*Filter, 1), sbf.filters...)
 		sbf.filters[0] = New(
 			uint64(float64(sbf.filters[1].Size)*sbf.s),
-			hashCountForFP(sbf.p))
+			hashCountForFP(sbf.p), sbf.opts...)
 		// sbf.dumpsFilters()
 	}
 	sbf.filters[0].Feed(s)
 	return sbf
 }
+
+// EstimateCardinality : Estimate the number of distinct elements fed into
+// sbf so far, using the Swamidass-Baldi estimator -(m/k) * ln(1 - X/m) on
+// each layer (m its bit count, k its hash count, X its set bit count) and
+// summing across layers.
+func (sbf *ScalableFilter) EstimateCardinality() uint64 {
+	var total float64
+	for _, f := range sbf.filters {
+		m := float64(f.Size * 8)
+		x := float64(f.store.popcount())
+		if x >= m {
+			x = m - 1 // avoid ln(0); a saturated layer underestimates regardless
+		}
+		total += -(m / float64(f.k)) * math.Log(1-x/m)
+	}
+	return uint64(total)
+}
+
+// Compact merges every layer of sbf into a single dense Filter sized to
+// the newest (largest, tightest) layer, for callers who know insertions
+// are done and want to drop the per-layer Match/memory overhead. Because
+// Size and k both change as sbf grows, a bit set in an older, smaller
+// layer does not generally sit where the merged filter's own section
+// width would look for it on a future Match — this is exact only when
+// every layer shares the same (Size, k), e.g. a filter that never grew
+// past its first layer, and otherwise trades a higher false-negative rate
+// on elements fed to older layers for a single filter with no further
+// layering.
+func (sbf *ScalableFilter) Compact() *Filter {
+	newest := sbf.filters[0]
+	merged := New(newest.Size, newest.k, sbf.opts...)
+	mergedBytes := merged.denseBytes()
+	var inserted uint64
+	for _, f := range sbf.filters {
+		fBytes := f.denseBytes()
+		for i, b := range fBytes {
+			mergedBytes[i] |= b
+		}
+		inserted += f.inserted
+	}
+	merged.inserted = inserted
+	return merged
+}
@@ -0,0 +1,48 @@
+package bloom
+
+import "testing"
+
+// TestScalableGrowth : Test that a layer grows only once its
+// EstimateFillRatio crosses the configured growthFillRatio, not before
+func TestScalableGrowth(t *testing.T) {
+	sbf := NewScalable(0.1, 2.0, 256, 0.8, 0.5)
+	for len(sbf.filters) == 1 {
+		sbf.Feed(randString(20))
+	}
+	if sbf.filters[1].EstimateFillRatio() <= 0.5 {
+		t.Errorf("filter grew a new layer before the oldest one crossed growthFillRatio: got %f", sbf.filters[1].EstimateFillRatio())
+	}
+}
+
+// TestScalableEstimateCardinality : Test that EstimateCardinality tracks
+// the number of distinct elements fed into a ScalableFilter, across layers
+func TestScalableEstimateCardinality(t *testing.T) {
+	sbf := NewDefaultScalable(0.01)
+	n := 2000
+	for i := 0; i < n; i++ {
+		sbf.Feed(randString(20))
+	}
+	est := sbf.EstimateCardinality()
+	if est < uint64(float64(n)*0.9) || est > uint64(float64(n)*1.1) {
+		t.Errorf("EstimateCardinality off by more than 10%%: got %d, want ~%d", est, n)
+	}
+}
+
+// TestScalableCompact : Test that Compact produces a single Filter that
+// still matches elements fed while the ScalableFilter had a single layer
+func TestScalableCompact(t *testing.T) {
+	sbf := NewDefaultScalable(0.1)
+	for _, v := range tests {
+		sbf.Feed(v)
+	}
+	if len(sbf.filters) != 1 {
+		t.Fatalf("test fixture grew past one layer, Compact's exactness guarantee does not apply: %d layers", len(sbf.filters))
+	}
+
+	compact := sbf.Compact()
+	for _, v := range tests {
+		if !compact.Match(v) {
+			t.Errorf("element lost when compacting a single-layer ScalableFilter : %s", v)
+		}
+	}
+}
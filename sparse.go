@@ -0,0 +1,147 @@
+package bloom
+
+import "sort"
+
+// sparsePromoteFillRatio is the EstimateFillRatio threshold above which a
+// Filter backed by sparseStore is promoted to denseStore. Below it, storing
+// only the set positions is far cheaper than a zeroed byte array; this is
+// the usual situation for a freshly grown ScalableFilter layer.
+const sparsePromoteFillRatio = 0.05
+
+// bitStore is the backing store for a Filter's bit array. setBit, isSet and
+// Merge dispatch through it so Filter (and, transitively, ScalableFilter)
+// stay agnostic to whether the array is dense or sparse.
+type bitStore interface {
+	setBit(n uint64)
+	isSet(n uint64) bool
+	popcount() uint64
+	toDense(size uint64) []byte
+	merge(other bitStore, size uint64) bitStore
+}
+
+// newStore returns the initial backing store for a freshly created or Reset
+// Filter. A filter with no entries yet has a fill ratio of zero, so it
+// starts out sparse and is promoted to dense by maybePromote once Feed
+// pushes it past sparsePromoteFillRatio.
+func newStore() bitStore {
+	return &sparseStore{}
+}
+
+// denseStore is a plain byte array, one bit per position: the original
+// Filter representation.
+type denseStore struct {
+	arr []byte
+}
+
+func (d *denseStore) setBit(n uint64)     { d.arr[n/8] |= 1 << (n % 8) }
+func (d *denseStore) isSet(n uint64) bool { return (d.arr[n/8] & (1 << (n % 8))) > 0 }
+func (d *denseStore) popcount() uint64    { return popcntSliceGo(d.arr) }
+func (d *denseStore) toDense(uint64) []byte {
+	return d.arr
+}
+
+// merge ORs other into d in place, promoting other to dense first if it
+// is currently sparse.
+func (d *denseStore) merge(other bitStore, size uint64) bitStore {
+	oth := other.toDense(size)
+	for i := range d.arr {
+		d.arr[i] |= oth[i]
+	}
+	return d
+}
+
+// sparseStore is just the sorted, deduplicated set bit positions: an
+// "array container", in Roaring bitmap terms. It is NOT a Roaring bitmap —
+// a real one partitions the address space into per-16-bit containers
+// (array/bitmap/run) and switches representation per container to stay
+// compact across a wide range of densities; this module has no go.mod to
+// vendor one, so sparseStore deliberately only covers the single case a
+// mostly-empty Filter needs (and pays an O(n) setBit for it, unlike a real
+// array container's own growth/splitting behavior). Do not assume it
+// scales the way a true Roaring-backed store would past sparsePromoteFillRatio.
+type sparseStore struct {
+	bits []uint64
+}
+
+func (s *sparseStore) setBit(n uint64) {
+	i := sort.Search(len(s.bits), func(i int) bool { return s.bits[i] >= n })
+	if i < len(s.bits) && s.bits[i] == n {
+		return
+	}
+	s.bits = append(s.bits, 0)
+	copy(s.bits[i+1:], s.bits[i:])
+	s.bits[i] = n
+}
+
+func (s *sparseStore) isSet(n uint64) bool {
+	i := sort.Search(len(s.bits), func(i int) bool { return s.bits[i] >= n })
+	return i < len(s.bits) && s.bits[i] == n
+}
+
+func (s *sparseStore) popcount() uint64 { return uint64(len(s.bits)) }
+
+func (s *sparseStore) toDense(size uint64) []byte {
+	arr := make([]byte, size)
+	for _, n := range s.bits {
+		arr[n/8] |= 1 << (n % 8)
+	}
+	return arr
+}
+
+// merge returns the union of s and other. If other is also sparse, the
+// result stays a sparseStore (merging the two sorted position lists); if
+// other is dense, staying sparse would mean testing every one of its bits,
+// so s promotes to dense first and ORs in other's bytes instead.
+func (s *sparseStore) merge(other bitStore, size uint64) bitStore {
+	oth, ok := other.(*sparseStore)
+	if !ok {
+		d := &denseStore{arr: s.toDense(size)}
+		return d.merge(other, size)
+	}
+	merged := make([]uint64, 0, len(s.bits)+len(oth.bits))
+	i, j := 0, 0
+	for i < len(s.bits) && j < len(oth.bits) {
+		switch {
+		case s.bits[i] < oth.bits[j]:
+			merged = append(merged, s.bits[i])
+			i++
+		case s.bits[i] > oth.bits[j]:
+			merged = append(merged, oth.bits[j])
+			j++
+		default:
+			merged = append(merged, s.bits[i])
+			i++
+			j++
+		}
+	}
+	merged = append(merged, s.bits[i:]...)
+	merged = append(merged, oth.bits[j:]...)
+	return &sparseStore{bits: merged}
+}
+
+// promote switches bf to a dense backing store, a no-op if it already is
+// one. Merge and the binary/willf export paths need a contiguous byte
+// array, and a Filter that has crossed sparsePromoteFillRatio is cheaper to
+// keep dense than to keep paying sparseStore's O(n) setBit.
+func (bf *Filter) promote() {
+	if _, ok := bf.store.(*denseStore); ok {
+		return
+	}
+	bf.store = &denseStore{arr: bf.store.toDense(bf.Size)}
+}
+
+// denseBytes returns bf's bit array as a contiguous byte slice, promoting
+// it in place first if it is currently sparse.
+func (bf *Filter) denseBytes() []byte {
+	bf.promote()
+	return bf.store.(*denseStore).arr
+}
+
+func (bf *Filter) maybePromote() {
+	if _, ok := bf.store.(*denseStore); ok {
+		return
+	}
+	if bf.EstimateFillRatio() > sparsePromoteFillRatio {
+		bf.promote()
+	}
+}
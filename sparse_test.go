@@ -0,0 +1,114 @@
+package bloom
+
+import "testing"
+
+// TestSparseStartsSparse : A freshly created Filter has a fill ratio of
+// zero, so it should start out backed by sparseStore.
+func TestSparseStartsSparse(t *testing.T) {
+	bf := New(FilterSize, 5)
+	if _, ok := bf.store.(*sparseStore); !ok {
+		t.Errorf("new Filter backed by %T, want *sparseStore", bf.store)
+	}
+}
+
+// TestSparsePromotion : Feeding enough elements to cross
+// sparsePromoteFillRatio promotes the Filter to denseStore, and Match still
+// sees every previously fed element across the promotion.
+func TestSparsePromotion(t *testing.T) {
+	bf := New(FilterSize, 5)
+	for _, v := range tests {
+		bf.Feed(v)
+	}
+	for bf.EstimateFillRatio() <= sparsePromoteFillRatio {
+		bf.Feed(randString(20))
+	}
+	if bf.EstimateFillRatio() <= sparsePromoteFillRatio {
+		t.Fatalf("test fixture does not cross sparsePromoteFillRatio, got %f", bf.EstimateFillRatio())
+	}
+	if _, ok := bf.store.(*denseStore); !ok {
+		t.Errorf("Filter past sparsePromoteFillRatio backed by %T, want *denseStore", bf.store)
+	}
+	for _, v := range tests {
+		if !bf.Match(v) {
+			t.Errorf("element lost when promoting sparse Filter to dense : %s", v)
+		}
+	}
+}
+
+// TestSparseMergeStaysSparse : Merging two still-sparse Filters keeps the
+// result sparse.
+func TestSparseMergeStaysSparse(t *testing.T) {
+	bf := New(FilterSize, 5)
+	oth := New(FilterSize, 5)
+	bf.Feed("foo")
+	oth.Feed("bar")
+
+	if err := bf.Merge(oth); err != nil {
+		t.Fatalf("Merge failed : %v", err)
+	}
+	if _, ok := bf.store.(*sparseStore); !ok {
+		t.Errorf("merge of two sparse Filters backed by %T, want *sparseStore", bf.store)
+	}
+	if !bf.Match("foo") || !bf.Match("bar") {
+		t.Errorf("element lost when merging two sparse filters")
+	}
+}
+
+// TestSparseMergeWithDense : Merging a sparse Filter into a dense one (or
+// vice versa) promotes to dense and keeps every element.
+func TestSparseMergeWithDense(t *testing.T) {
+	bf := New(FilterSize, 5)
+	for _, v := range tests {
+		bf.Feed(v)
+	}
+	bf.promote()
+
+	oth := New(FilterSize, 5)
+	oth.Feed("extra")
+
+	if err := bf.Merge(oth); err != nil {
+		t.Fatalf("Merge failed : %v", err)
+	}
+	if _, ok := bf.store.(*denseStore); !ok {
+		t.Errorf("merge involving a dense Filter backed by %T, want *denseStore", bf.store)
+	}
+	for _, v := range append(append([]string{}, tests...), "extra") {
+		if !bf.Match(v) {
+			t.Errorf("element lost when merging sparse into dense : %s", v)
+		}
+	}
+}
+
+/*
+ *
+ *
+ *  BENCHMARKS
+ *
+ *
+ */
+
+// benchSparseFootprint feeds bf until its EstimateFillRatio reaches fill,
+// reporting bytes allocated by its backing store at that point. It never
+// crosses sparsePromoteFillRatio in the 0.001/0.01 cases, so those report
+// sparseStore's footprint; the 0.1 case promotes to dense partway through
+// and reports denseStore's.
+func benchSparseFootprint(fill float64, b *testing.B) {
+	const size = 1 << 13 // 64K bits
+	for n := 0; n < b.N; n++ {
+		bf := New(size, 5)
+		target := uint64(fill * float64(size*8))
+		for bf.inserted < target {
+			bf.Feed(randString(20))
+		}
+		switch s := bf.store.(type) {
+		case *sparseStore:
+			b.SetBytes(int64(len(s.bits) * 8))
+		case *denseStore:
+			b.SetBytes(int64(len(s.arr)))
+		}
+	}
+}
+
+func BenchmarkSparseFootprint0_1Percent(b *testing.B) { benchSparseFootprint(0.001, b) }
+func BenchmarkSparseFootprint1Percent(b *testing.B)   { benchSparseFootprint(0.01, b) }
+func BenchmarkSparseFootprint10Percent(b *testing.B)  { benchSparseFootprint(0.1, b) }
@@ -1,64 +1,55 @@
 package bloom
 
 import (
-	"crypto/md5"
-	"crypto/sha1"
-	"crypto/sha256"
-	"crypto/sha512"
-	"encoding/binary"
 	"math"
+	"unsafe"
 )
 
-// Bug(makeHashes) Panic if required hashes total size is bigger than a sha512
-
-// Panic if required hashes are larger than a sha512 digest
-// Final hash function, compute k hashes of size characters from
-// digest.
-func makeHashes(digest []byte, size uint64, k uint64) []uint64 {
-	if uint64(len(digest)) < size*k || size > 8 {
-		panic("Digest is too small to address all the filter")
-	}
-	res := make([]uint64, k)
-	for i := uint64(0); i < k; i++ {
-		raw := digest[i*size : ((i + 1) * size)]
-		res[i] = binary.BigEndian.Uint64(append(make([]byte, 8-len(raw)), raw...))
+// generateHasher returns the function used by a Filter to compute its k bit
+// indices for an input. It hashes the input once with hasher into a 128-bit
+// digest (h1, h2) and derives the k positions using the Kirsch-Mitzenmacher
+// double hashing construction:
+//
+//	g_i(x) = h1 + i*h2, for i = 0..k-1
+//
+// which gives asymptotically the same false-positive rate as k independent
+// hashes for a single hash computation. Callers (Filter/Match/Feed) still
+// reduce each g_i modulo the section size, so a 128-bit digest comfortably
+// covers any practical k. The returned func writes its k positions into the
+// caller-supplied dst (which must have length k) instead of allocating, so
+// Feed/Match can reuse a single scratch buffer across calls.
+func generateHasher(k uint64, hasher Hasher) func(inp []byte, dst []uint64) {
+	return func(inp []byte, dst []uint64) {
+		h1, h2 := hasher(inp)
+		for i := uint64(0); i < k; i++ {
+			dst[i] = h1 + i*h2
+		}
 	}
-	return res
 }
 
-// Return the function used in the filter for hashing
-//
-func hashingRoutine(size uint64, k uint64) func([]byte) []uint64 {
-	hashSize := size * k
-
-	return func(inp []byte) []uint64 {
-
-		switch {
-		case hashSize > 48:
-			digest := (sha512.Sum512(inp))
-			return makeHashes(digest[:], size, k)
-		case hashSize > 32:
-			digest := (sha512.Sum384(inp))
-			return makeHashes(digest[:], size, k)
-		case hashSize > 20:
-			digest := (sha256.Sum256(inp))
-			return makeHashes(digest[:], size, k)
-		case hashSize > 16:
-			digest := (sha1.Sum(inp))
-			return makeHashes(digest[:], size, k)
-		default:
-			digest := (md5.Sum(inp))
-			return makeHashes(digest[:], size, k)
-		}
+// bytesView returns a zero-copy []byte view of s. The returned slice aliases
+// s's underlying storage, so it must only be read (never written to or
+// retained past the call it's passed to) and s must outlive it; this is
+// exactly how Feed/Match use it to hash a string without copying it into a
+// fresh []byte on every call.
+func bytesView(s string) []byte {
+	if len(s) == 0 {
+		return nil
 	}
+	return unsafe.Slice(unsafe.StringData(s), len(s))
 }
 
-// M bits, k hashers
-//
-func generateHasher(k uint64, M uint64) func([]byte) [](uint64) {
-	minIdx := (M / k)
-	minHashDigits := uint64(math.Ceil(math.Log(float64(minIdx)) / math.Log(16.0)))
-	return hashingRoutine(minHashDigits, k)
+// hashPositions computes bf's k bit positions for s into bf's reusable
+// scratch buffer, growing it on first use (or after a hasher swap changes
+// k), and returns it. This keeps Feed/Match allocation-free after warmup,
+// which matters most for the mmap-backed Filter variant where the hot path
+// is otherwise the cost of a single page write.
+func (bf *Filter) hashPositions(s string) []uint64 {
+	if uint64(len(bf.hashBuf)) != bf.k {
+		bf.hashBuf = make([]uint64, bf.k)
+	}
+	bf.hasher(bytesView(s), bf.hashBuf)
+	return bf.hashBuf
 }
 
 // n : number to test, b : base
@@ -78,13 +69,13 @@ func hashCountForFP(fp float64) uint64 {
 
 // set nth bit to 1
 func (bf *Filter) setBit(n uint64) *Filter {
-	bf.arr[n/8] |= (1 << (n % 8))
+	bf.store.setBit(n)
 	return bf
 }
 
 // n : bit index
 func (bf *Filter) isSet(n uint64) bool {
-	return (bf.arr[n/8] & (1 << (n % 8))) > 0
+	return bf.store.isSet(n)
 }
 
 // Estimate gives an estimation of optimal configuration for